@@ -12,7 +12,6 @@ import (
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/idtools"
 	"github.com/docker/docker/pkg/progress"
-	"github.com/docker/docker/pkg/streamformatter"
 	"github.com/docker/docker/pkg/stringid"
 	"github.com/docker/docker/registry"
 	"github.com/pkg/errors"
@@ -24,6 +23,12 @@ type ImageComponent interface {
 	SquashImage(from string, to string) (string, error)
 	TagImageWithReference(image.ID, reference.Named) error
 	PushImage(ctx context.Context, image, tag string, metaHeaders map[string][]string, authConfig *types.AuthConfig, output progress.Output) error
+	// PullImage pulls image:tag and returns the resolved manifest digest, so
+	// callers can content-address what was actually fetched.
+	PullImage(ctx context.Context, image, tag string, metaHeaders map[string][]string, authConfig *types.AuthConfig, output progress.Output) (digest string, err error)
+	ExportImage(id string, refs []reference.Named, w io.Writer) error
+	ContainerRunner
+	SignaturePusher
 }
 
 // Backend provides build functionality to the API router
@@ -31,26 +36,36 @@ type Backend struct {
 	manager         *dockerfile.BuildManager
 	imageComponent  ImageComponent
 	registryService registry.Service
+	signer          Signer
 }
 
-// NewBackend creates a new build backend from components
-func NewBackend(components ImageComponent, builderBackend builder.Backend, idMappings *idtools.IDMappings, registryService registry.Service) *Backend {
+// NewBackend creates a new build backend from components. A nil signer
+// defaults to NoopSigner, so builds are unsigned unless the daemon opts in.
+func NewBackend(components ImageComponent, builderBackend builder.Backend, idMappings *idtools.IDMappings, registryService registry.Service, signer Signer) *Backend {
 	manager := dockerfile.NewBuildManager(builderBackend, idMappings)
-	return &Backend{imageComponent: components, manager: manager, registryService: registryService}
+	if signer == nil {
+		signer = NoopSigner{}
+	}
+	return &Backend{imageComponent: components, manager: manager, registryService: registryService, signer: signer}
 }
 
 // Build builds an image from a Source
 func (b *Backend) Build(ctx context.Context, config backend.BuildConfig) (string, error) {
 	options := config.Options
+	pushTargets := resolvePushTargets(options)
 	tags := options.Tags
-	if options.PushAs != "" {
-		tags = append(tags, options.PushAs)
+	for _, target := range pushTargets {
+		tags = append(tags, target.Ref)
 	}
 	tagger, err := NewTagger(b.imageComponent, config.ProgressWriter.StdoutFormatter, tags)
 	if err != nil {
 		return "", err
 	}
 
+	if err := resolveCacheFrom(ctx, b, options.CacheFrom, options.AuthConfigs, config.ProgressWriter.StdoutFormatter); err != nil {
+		return "", err
+	}
+
 	build, err := b.manager.Build(ctx, config)
 	if err != nil {
 		return "", err
@@ -64,27 +79,40 @@ func (b *Backend) Build(ctx context.Context, config backend.BuildConfig) (string
 	}
 
 	stdout := config.ProgressWriter.StdoutFormatter
+	if err := runPostCommitHook(ctx, b.imageComponent, imageID, options.PostCommit, stdout); err != nil {
+		return "", err
+	}
+
 	fmt.Fprintf(stdout, "Successfully built %s\n", stringid.TruncateID(imageID))
 	err = tagger.TagImages(image.ID(imageID))
-
-	if options.PushAs != "" {
-		err = b.pushImage(ctx, options.PushAs, options.AuthConfigs, stdout)
-		return imageID, err
+	if err != nil {
+		return "", err
 	}
-	return imageID, err
-}
 
-func (b *Backend) pushImage(ctx context.Context, pushAs string, authConfigs map[string]types.AuthConfig, output io.Writer) error {
-	ref, err := reference.ParseNormalizedNamed(pushAs)
-	if err != nil {
-		return err
+	if options.Output != nil {
+		fmt.Fprintf(stdout, "Exporting to OCI image layout\n")
+		if err := exportImage(b.imageComponent, imageID, options.Output, stdout); err != nil {
+			return "", err
+		}
 	}
-	repoInfo, err := b.registryService.ResolveRepository(ref)
-	if err != nil {
-		return err
+
+	if len(pushTargets) > 0 {
+		signRefs := make([]reference.Named, 0, len(pushTargets))
+		for _, target := range pushTargets {
+			ref, err := reference.ParseNormalizedNamed(target.Ref)
+			if err != nil {
+				return "", err
+			}
+			signRefs = append(signRefs, ref)
+		}
+		if err := signTags(ctx, b.signer, signRefs, image.ID(imageID), stdout); err != nil {
+			return "", err
+		}
+
+		err = b.pushAll(ctx, imageID, pushTargets, options.AuthConfigs, stdout)
+		return imageID, err
 	}
-	authConfig := registry.ResolveAuthConfig(authConfigs, repoInfo.Index)
-	return b.imageComponent.PushImage(ctx, ref.String(), "", map[string][]string{}, &authConfig, streamformatter.NewProgressOutput(output))
+	return imageID, err
 }
 
 func squashBuild(build *builder.Result, imageComponent ImageComponent) (string, error) {
@@ -0,0 +1,220 @@
+package build
+
+import (
+	"container/list"
+	"io"
+	"sync"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/streamformatter"
+	"github.com/docker/docker/registry"
+	"golang.org/x/net/context"
+	"golang.org/x/sync/singleflight"
+)
+
+// cacheImagePullPrefix tags progress for cache-from pulls so it reads
+// distinctly from the build's own step output.
+const cacheImagePullPrefix = "[internal] load cache"
+
+// cachePullLRUSize bounds how many resolved cache-from references are
+// remembered across builds to avoid re-pulling the same cache image when
+// several builds race on the same FROM/--cache-from references.
+const cachePullLRUSize = 32
+
+// cachePullCache is a small in-memory LRU of cache-from images that have
+// already been pulled locally. Entries are keyed both by the resolved
+// manifest digest (so a later digest-pinned reference is recognized) and by
+// the normalized reference string (so a later pull of the same tag is too,
+// since most --cache-from references are tags, not digests).
+type cachePullCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+}
+
+func newCachePullCache(size int) *cachePullCache {
+	return &cachePullCache{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *cachePullCache) has(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return false
+	}
+	c.order.MoveToFront(elem)
+	return true
+}
+
+func (c *cachePullCache) add(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+	elem := c.order.PushFront(key)
+	c.items[key] = elem
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		if oldest == nil {
+			break
+		}
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(string))
+	}
+}
+
+// globalCachePullCache is shared across builds on this daemon so concurrent
+// builds referencing the same cache-from image don't each pull it.
+var globalCachePullCache = newCachePullCache(cachePullLRUSize)
+
+// cachePullGroup collapses concurrent pulls of the same cache-from
+// reference into a single request in flight, so two builds racing on an
+// uncached image don't both pull it before either has a chance to populate
+// globalCachePullCache.
+var cachePullGroup singleflight.Group
+
+// pullBroadcasters tracks, per in-flight reference, the set of callers
+// currently waiting on cachePullGroup for that reference. singleflight.Do
+// only ever invokes the function passed by the first caller to arrive, so
+// without this every other build waiting on the same ref would see no
+// progress output at all until the pull finishes. Each waiter registers its
+// own output here before calling Do and is fanned the winner's progress.
+var (
+	pullBroadcastersMu sync.Mutex
+	pullBroadcasters   = map[string]*pullBroadcaster{}
+)
+
+// pullBroadcaster fans writes out to every subscribed writer, so a single
+// in-flight pull can report progress to every build waiting on it.
+type pullBroadcaster struct {
+	mu      sync.Mutex
+	writers []io.Writer
+}
+
+func (b *pullBroadcaster) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, w := range b.writers {
+		// Best-effort: a write failing for one waiter shouldn't abort the
+		// pull for every other waiter sharing it.
+		w.Write(p)
+	}
+	return len(p), nil
+}
+
+func (b *pullBroadcaster) subscribe(w io.Writer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.writers = append(b.writers, w)
+}
+
+func (b *pullBroadcaster) unsubscribe(w io.Writer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, existing := range b.writers {
+		if existing == w {
+			b.writers = append(b.writers[:i], b.writers[i+1:]...)
+			return
+		}
+	}
+}
+
+// acquirePullBroadcaster returns the shared broadcaster for key, creating it
+// if this is the first waiter.
+func acquirePullBroadcaster(key string) *pullBroadcaster {
+	pullBroadcastersMu.Lock()
+	defer pullBroadcastersMu.Unlock()
+	b, ok := pullBroadcasters[key]
+	if !ok {
+		b = &pullBroadcaster{}
+		pullBroadcasters[key] = b
+	}
+	return b
+}
+
+// releasePullBroadcaster drops the broadcaster for key once it has no
+// subscribers left, so pullBroadcasters doesn't grow without bound.
+func releasePullBroadcaster(key string, b *pullBroadcaster) {
+	pullBroadcastersMu.Lock()
+	defer pullBroadcastersMu.Unlock()
+	b.mu.Lock()
+	empty := len(b.writers) == 0
+	b.mu.Unlock()
+	if empty {
+		delete(pullBroadcasters, key)
+	}
+}
+
+// resolveCacheFrom pulls every reference in cacheFrom from its registry so
+// the dockerfile builder can use it as a layer cache source. A reference
+// already recorded in globalCachePullCache by an earlier pull on this daemon
+// is skipped; the local image store itself is not checked, so an image that
+// is present locally but not recorded there is pulled again.
+func resolveCacheFrom(ctx context.Context, b *Backend, cacheFrom []string, authConfigs map[string]types.AuthConfig, output io.Writer) error {
+	for _, from := range cacheFrom {
+		if err := b.pullCacheImage(ctx, from, authConfigs, output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Backend) pullCacheImage(ctx context.Context, from string, authConfigs map[string]types.AuthConfig, output io.Writer) error {
+	ref, err := reference.ParseNormalizedNamed(from)
+	if err != nil {
+		return err
+	}
+
+	// A canonical reference already names its digest, so it can be checked
+	// against the cache directly. A tag reference can't be until it's
+	// resolved, but it's cached under its own string once pulled, so a
+	// repeated pull of the same tag still hits the cache below.
+	cacheKey := ref.String()
+	if digested, ok := ref.(reference.Canonical); ok {
+		cacheKey = digested.Digest().String()
+	}
+	if globalCachePullCache.has(cacheKey) {
+		return nil
+	}
+
+	groupKey := ref.String()
+	broadcaster := acquirePullBroadcaster(groupKey)
+	waiterOutput := newPrefixWriter(cacheImagePullPrefix, output, nil)
+	broadcaster.subscribe(waiterOutput)
+	defer func() {
+		broadcaster.unsubscribe(waiterOutput)
+		releasePullBroadcaster(groupKey, broadcaster)
+	}()
+
+	_, err, _ = cachePullGroup.Do(groupKey, func() (interface{}, error) {
+		return nil, b.doPullCacheImage(ctx, ref, authConfigs, broadcaster)
+	})
+	return err
+}
+
+func (b *Backend) doPullCacheImage(ctx context.Context, ref reference.Named, authConfigs map[string]types.AuthConfig, output io.Writer) error {
+	repoInfo, err := b.registryService.ResolveRepository(ref)
+	if err != nil {
+		return err
+	}
+	authConfig := registry.ResolveAuthConfig(authConfigs, repoInfo.Index)
+
+	digest, err := b.imageComponent.PullImage(ctx, ref.String(), "", map[string][]string{}, &authConfig, streamformatter.NewProgressOutput(output))
+	if err != nil {
+		return err
+	}
+	globalCachePullCache.add(ref.String())
+	if digest != "" {
+		globalCachePullCache.add(digest)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package build
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/progress"
+	"golang.org/x/net/context"
+	"golang.org/x/sync/singleflight"
+)
+
+func TestCachePullCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newCachePullCache(2)
+	c.add("sha256:a")
+	c.add("sha256:b")
+	c.has("sha256:a") // touch a so it's more recently used than b
+	c.add("sha256:c") // evicts b, the least recently used
+
+	if !c.has("sha256:a") {
+		t.Fatal("expected sha256:a to still be cached")
+	}
+	if c.has("sha256:b") {
+		t.Fatal("expected sha256:b to have been evicted")
+	}
+	if !c.has("sha256:c") {
+		t.Fatal("expected sha256:c to be cached")
+	}
+}
+
+type pullOverride struct {
+	fakeImageComponent
+	pull func(ctx context.Context, image, tag string, metaHeaders map[string][]string, authConfig *types.AuthConfig, output progress.Output) (string, error)
+}
+
+func (p pullOverride) PullImage(ctx context.Context, image, tag string, metaHeaders map[string][]string, authConfig *types.AuthConfig, output progress.Output) (string, error) {
+	return p.pull(ctx, image, tag, metaHeaders, authConfig, output)
+}
+
+func TestPullCacheImageSkipsAlreadyCachedDigest(t *testing.T) {
+	globalCachePullCache = newCachePullCache(cachePullLRUSize)
+	const digest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	globalCachePullCache.add(digest)
+
+	var calls int32
+	component := pullOverride{pull: func(ctx context.Context, image, tag string, metaHeaders map[string][]string, authConfig *types.AuthConfig, output progress.Output) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		return digest, nil
+	}}
+
+	b := &Backend{imageComponent: component, registryService: fakeRegistryService{}}
+	var out bytes.Buffer
+	ref := "example.com/foo@" + digest
+	if err := b.pullCacheImage(context.Background(), ref, nil, &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("expected no pull for an already-cached digest, got %d calls", calls)
+	}
+}
+
+func TestPullCacheImageCachesTagAfterFirstPull(t *testing.T) {
+	globalCachePullCache = newCachePullCache(cachePullLRUSize)
+	cachePullGroup = singleflight.Group{}
+
+	var calls int32
+	component := pullOverride{pull: func(ctx context.Context, image, tag string, metaHeaders map[string][]string, authConfig *types.AuthConfig, output progress.Output) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		return "sha256:3333333333333333333333333333333333333333333333333333333333333333", nil
+	}}
+
+	b := &Backend{imageComponent: component, registryService: fakeRegistryService{}}
+	var out bytes.Buffer
+	for i := 0; i < 3; i++ {
+		if err := b.pullCacheImage(context.Background(), "example.com/foo:cache", nil, &out); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected a tag ref to be pulled once and served from cache afterwards, got %d pulls", calls)
+	}
+}
+
+func TestPullBroadcasterFansWriteToAllSubscribers(t *testing.T) {
+	b := &pullBroadcaster{}
+	var a, c bytes.Buffer
+	b.subscribe(&a)
+	b.subscribe(&c)
+
+	if _, err := b.Write([]byte("progress\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if a.String() != "progress\n" {
+		t.Fatalf("subscriber a got %q, want %q", a.String(), "progress\n")
+	}
+	if c.String() != "progress\n" {
+		t.Fatalf("subscriber c got %q, want %q", c.String(), "progress\n")
+	}
+}
+
+func TestPullBroadcasterUnsubscribeStopsDelivery(t *testing.T) {
+	b := &pullBroadcaster{}
+	var a, c bytes.Buffer
+	b.subscribe(&a)
+	b.subscribe(&c)
+	b.unsubscribe(&a)
+
+	if _, err := b.Write([]byte("progress\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if a.Len() != 0 {
+		t.Fatalf("expected unsubscribed writer to receive nothing, got %q", a.String())
+	}
+	if c.String() != "progress\n" {
+		t.Fatalf("subscriber c got %q, want %q", c.String(), "progress\n")
+	}
+}
+
+func TestPullCacheImageDedupsConcurrentPullsOfSameTag(t *testing.T) {
+	globalCachePullCache = newCachePullCache(cachePullLRUSize)
+	cachePullGroup = singleflight.Group{}
+
+	var calls int32
+	component := pullOverride{pull: func(ctx context.Context, image, tag string, metaHeaders map[string][]string, authConfig *types.AuthConfig, output progress.Output) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		return "sha256:2222222222222222222222222222222222222222222222222222222222222222", nil
+	}}
+
+	b := &Backend{imageComponent: component, registryService: fakeRegistryService{}}
+	var out bytes.Buffer
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := b.pullCacheImage(context.Background(), "example.com/foo:cache", nil, &out); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("expected a single underlying pull for concurrent requests of the same tag, got %d", calls)
+	}
+}
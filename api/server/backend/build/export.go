@@ -0,0 +1,33 @@
+package build
+
+import (
+	"io"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+)
+
+// exportImage streams imageID out as an OCI image layout tar, writing
+// index.json and the manifest/config/layer blobs under blobs/sha256/... to w.
+// refs, if any, are recorded as annotations on the index so consumers such as
+// skopeo or crane can resolve a name without a registry.
+func exportImage(imageComponent ImageComponent, imageID string, output *types.ImageExportOutput, w io.Writer) error {
+	if output == nil {
+		return nil
+	}
+
+	refs := make([]reference.Named, 0, len(output.Refs))
+	for _, r := range output.Refs {
+		ref, err := reference.ParseNormalizedNamed(r)
+		if err != nil {
+			return errors.Wrapf(err, "invalid output ref %q", r)
+		}
+		refs = append(refs, ref)
+	}
+
+	if err := imageComponent.ExportImage(imageID, refs, w); err != nil {
+		return errors.Wrap(err, "failed to export image as OCI layout")
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package build
+
+import (
+	"io"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/pkg/progress"
+	"github.com/docker/docker/registry"
+	"golang.org/x/net/context"
+)
+
+// fakeImageComponent is a no-op ImageComponent that individual tests
+// embed and override only the methods they exercise.
+type fakeImageComponent struct{}
+
+func (fakeImageComponent) SquashImage(from, to string) (string, error) { return from, nil }
+func (fakeImageComponent) TagImageWithReference(image.ID, reference.Named) error {
+	return nil
+}
+func (fakeImageComponent) PushImage(ctx context.Context, image, tag string, metaHeaders map[string][]string, authConfig *types.AuthConfig, output progress.Output) error {
+	return nil
+}
+func (fakeImageComponent) PullImage(ctx context.Context, image, tag string, metaHeaders map[string][]string, authConfig *types.AuthConfig, output progress.Output) (string, error) {
+	return "", nil
+}
+func (fakeImageComponent) ExportImage(id string, refs []reference.Named, w io.Writer) error {
+	return nil
+}
+func (fakeImageComponent) CreateFromImage(ctx context.Context, imageID string, cmd []string) (string, error) {
+	return "container", nil
+}
+func (fakeImageComponent) AttachContainer(ctx context.Context, containerID string, stdout, stderr io.Writer) error {
+	return nil
+}
+func (fakeImageComponent) StartContainer(ctx context.Context, containerID string) error {
+	return nil
+}
+func (fakeImageComponent) WaitContainer(ctx context.Context, containerID string) (int64, error) {
+	return 0, nil
+}
+func (fakeImageComponent) RemoveContainer(ctx context.Context, containerID string) error {
+	return nil
+}
+func (fakeImageComponent) PushSignature(ctx context.Context, ref reference.Named, imageID image.ID, authConfig *types.AuthConfig, sig []byte) error {
+	return nil
+}
+
+// fakeRegistryService resolves every reference to a RepositoryInfo named
+// after the reference's domain, so per-registry auth lookups in tests
+// behave like they would against a real registry.Service.
+type fakeRegistryService struct{}
+
+func (fakeRegistryService) ResolveRepository(name reference.Named) (*registry.RepositoryInfo, error) {
+	return &registry.RepositoryInfo{Index: &registry.IndexInfo{Name: reference.Domain(name)}}, nil
+}
@@ -0,0 +1,62 @@
+package build
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// ContainerRunner provides the minimal container lifecycle needed to run a
+// command against a freshly built image, used by post-commit build hooks.
+type ContainerRunner interface {
+	CreateFromImage(ctx context.Context, imageID string, cmd []string) (containerID string, err error)
+	AttachContainer(ctx context.Context, containerID string, stdout, stderr io.Writer) error
+	StartContainer(ctx context.Context, containerID string) error
+	WaitContainer(ctx context.Context, containerID string) (exitCode int64, err error)
+	RemoveContainer(ctx context.Context, containerID string) error
+}
+
+// runPostCommitHook runs the build's post-commit hook, if one was requested,
+// inside an ephemeral container created from imageID. Hook output is copied
+// to output, and a non-zero exit aborts the build.
+func runPostCommitHook(ctx context.Context, imageComponent ImageComponent, imageID string, hook *types.PostCommit, output io.Writer) error {
+	if hook == nil || hook.Empty() {
+		return nil
+	}
+
+	cmd := hook.Cmd()
+	fmt.Fprintf(output, "Running post-commit hook: %s\n", strings.Join(cmd, " "))
+
+	containerID, err := imageComponent.CreateFromImage(ctx, imageID, cmd)
+	if err != nil {
+		return errors.Wrap(err, "post-commit hook: failed to create container")
+	}
+	defer func() {
+		if rmErr := imageComponent.RemoveContainer(ctx, containerID); rmErr != nil {
+			fmt.Fprintf(output, "Warning: failed to clean up post-commit hook container %s: %v\n", containerID, rmErr)
+		}
+	}()
+
+	// Attach before starting so none of the hook's output is missed between
+	// the container starting and the client catching up to it.
+	if err := imageComponent.AttachContainer(ctx, containerID, output, output); err != nil {
+		return errors.Wrap(err, "post-commit hook: failed to attach to container")
+	}
+
+	if err := imageComponent.StartContainer(ctx, containerID); err != nil {
+		return errors.Wrap(err, "post-commit hook: failed to start container")
+	}
+
+	exitCode, err := imageComponent.WaitContainer(ctx, containerID)
+	if err != nil {
+		return errors.Wrap(err, "post-commit hook: failed waiting for container")
+	}
+	if exitCode != 0 {
+		return errors.Errorf("post-commit hook failed with exit code %d", exitCode)
+	}
+	return nil
+}
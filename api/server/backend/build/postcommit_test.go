@@ -0,0 +1,138 @@
+package build
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"golang.org/x/net/context"
+)
+
+type postCommitOverride struct {
+	fakeImageComponent
+	createFromImage func(ctx context.Context, imageID string, cmd []string) (string, error)
+	attachContainer func(ctx context.Context, containerID string, stdout, stderr io.Writer) error
+	startContainer  func(ctx context.Context, containerID string) error
+	waitContainer   func(ctx context.Context, containerID string) (int64, error)
+	removeContainer func(ctx context.Context, containerID string) error
+}
+
+func (p postCommitOverride) CreateFromImage(ctx context.Context, imageID string, cmd []string) (string, error) {
+	if p.createFromImage != nil {
+		return p.createFromImage(ctx, imageID, cmd)
+	}
+	return "container", nil
+}
+
+func (p postCommitOverride) AttachContainer(ctx context.Context, containerID string, stdout, stderr io.Writer) error {
+	if p.attachContainer != nil {
+		return p.attachContainer(ctx, containerID, stdout, stderr)
+	}
+	return nil
+}
+
+func (p postCommitOverride) StartContainer(ctx context.Context, containerID string) error {
+	if p.startContainer != nil {
+		return p.startContainer(ctx, containerID)
+	}
+	return nil
+}
+
+func (p postCommitOverride) WaitContainer(ctx context.Context, containerID string) (int64, error) {
+	if p.waitContainer != nil {
+		return p.waitContainer(ctx, containerID)
+	}
+	return 0, nil
+}
+
+func (p postCommitOverride) RemoveContainer(ctx context.Context, containerID string) error {
+	if p.removeContainer != nil {
+		return p.removeContainer(ctx, containerID)
+	}
+	return nil
+}
+
+func TestRunPostCommitHookNoopWhenEmpty(t *testing.T) {
+	component := postCommitOverride{createFromImage: func(ctx context.Context, imageID string, cmd []string) (string, error) {
+		t.Fatal("expected no container to be created for an empty hook")
+		return "", nil
+	}}
+
+	var out bytes.Buffer
+	if err := runPostCommitHook(context.Background(), component, "image", nil, &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := runPostCommitHook(context.Background(), component, "image", &types.PostCommit{}, &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRunPostCommitHookAbortsOnNonZeroExit(t *testing.T) {
+	var removed bool
+	component := postCommitOverride{
+		waitContainer: func(ctx context.Context, containerID string) (int64, error) {
+			return 1, nil
+		},
+		removeContainer: func(ctx context.Context, containerID string) error {
+			removed = true
+			return nil
+		},
+	}
+
+	var out bytes.Buffer
+	hook := &types.PostCommit{Command: []string{"false"}}
+	err := runPostCommitHook(context.Background(), component, "image", hook, &out)
+	if err == nil {
+		t.Fatal("expected a non-zero exit code to abort the build")
+	}
+	if !removed {
+		t.Fatal("expected the hook container to be removed even after a failing exit code")
+	}
+}
+
+func TestRunPostCommitHookRemovesContainerOnStartFailure(t *testing.T) {
+	var removed bool
+	component := postCommitOverride{
+		startContainer: func(ctx context.Context, containerID string) error {
+			return io.ErrClosedPipe
+		},
+		removeContainer: func(ctx context.Context, containerID string) error {
+			removed = true
+			return nil
+		},
+	}
+
+	var out bytes.Buffer
+	hook := &types.PostCommit{Command: []string{"true"}}
+	err := runPostCommitHook(context.Background(), component, "image", hook, &out)
+	if err == nil {
+		t.Fatal("expected the start failure to propagate")
+	}
+	if !removed {
+		t.Fatal("expected the hook container to be removed even though it never started")
+	}
+}
+
+func TestRunPostCommitHookRemovesContainerOnWaitFailure(t *testing.T) {
+	var removed bool
+	component := postCommitOverride{
+		waitContainer: func(ctx context.Context, containerID string) (int64, error) {
+			return 0, io.ErrUnexpectedEOF
+		},
+		removeContainer: func(ctx context.Context, containerID string) error {
+			removed = true
+			return nil
+		},
+	}
+
+	var out bytes.Buffer
+	hook := &types.PostCommit{Command: []string{"true"}}
+	err := runPostCommitHook(context.Background(), component, "image", hook, &out)
+	if err == nil {
+		t.Fatal("expected the wait failure to propagate")
+	}
+	if !removed {
+		t.Fatal("expected the hook container to be removed even after a failed wait")
+	}
+}
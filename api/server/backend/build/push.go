@@ -0,0 +1,197 @@
+package build
+
+import (
+	goerrors "errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/streamformatter"
+	"github.com/docker/docker/registry"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// maxConcurrentPushes bounds how many registries a single build will publish
+// to at once, so a long PushTargets list can't exhaust outbound connections.
+const maxConcurrentPushes = 3
+
+const maxPushRetries = 3
+
+// resolvePushTargets merges the deprecated single-target PushAs option into
+// the PushTargets slice so callers only need to handle one list.
+func resolvePushTargets(options types.ImageBuildOptions) []types.BuildPushTarget {
+	targets := options.PushTargets
+	if options.PushAs != "" {
+		targets = append(targets, types.BuildPushTarget{Ref: options.PushAs})
+	}
+	return targets
+}
+
+// pushAll publishes imageID to every target concurrently, bounded by
+// maxConcurrentPushes, retrying each target on transient registry errors.
+// Progress from each target is tagged with its reference so interleaved
+// output stays attributable; all targets share a single mutex so writes to
+// the underlying output stream are actually serialized across goroutines.
+func (b *Backend) pushAll(ctx context.Context, imageID string, targets []types.BuildPushTarget, authConfigs map[string]types.AuthConfig, output io.Writer) error {
+	if len(targets) == 0 {
+		return nil
+	}
+
+	sem := make(chan struct{}, maxConcurrentPushes)
+	errs := make([]error, len(targets))
+
+	outputMu := &sync.Mutex{}
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, target types.BuildPushTarget) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			taggedOutput := newPrefixWriter(target.Ref, output, outputMu)
+			errs[i] = b.pushTargetWithRetry(ctx, imageID, target, authConfigs, taggedOutput)
+		}(i, target)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return errors.Wrapf(err, "failed to push %s", targets[i].Ref)
+		}
+	}
+	return nil
+}
+
+func (b *Backend) pushTargetWithRetry(ctx context.Context, imageID string, target types.BuildPushTarget, authConfigs map[string]types.AuthConfig, output io.Writer) error {
+	var err error
+	for attempt := 0; attempt < maxPushRetries; attempt++ {
+		if attempt > 0 {
+			retryable, retryAfter := isRetryablePushError(err)
+			if !retryable {
+				break
+			}
+			backoff := retryAfter
+			if backoff <= 0 {
+				backoff = time.Duration(attempt*attempt) * time.Second
+			}
+			fmt.Fprintf(output, "retrying push after transient error: %v (attempt %d/%d)\n", err, attempt+1, maxPushRetries)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		err = b.pushTarget(ctx, imageID, target, authConfigs, output)
+		if err == nil {
+			return nil
+		}
+		if retryable, _ := isRetryablePushError(err); !retryable {
+			return err
+		}
+	}
+	return err
+}
+
+func (b *Backend) pushTarget(ctx context.Context, imageID string, target types.BuildPushTarget, authConfigs map[string]types.AuthConfig, output io.Writer) error {
+	ref, err := reference.ParseNormalizedNamed(target.Ref)
+	if err != nil {
+		return err
+	}
+	repoInfo, err := b.registryService.ResolveRepository(ref)
+	if err != nil {
+		return err
+	}
+	authConfig := registry.ResolveAuthConfig(authConfigs, repoInfo.Index)
+	if target.AuthKey != "" {
+		if keyed, ok := authConfigs[target.AuthKey]; ok {
+			authConfig = keyed
+		}
+	}
+	return b.imageComponent.PushImage(ctx, ref.String(), "", map[string][]string{}, &authConfig, streamformatter.NewProgressOutput(output))
+}
+
+// HTTPStatusError is the error ImageComponent.PushImage implementations
+// should return (optionally wrapped with errors.Wrap) when a push fails
+// because of an HTTP-level response from the registry, so callers can
+// distinguish a transient failure from a permanent one instead of guessing
+// from the rendered error text.
+type HTTPStatusError struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("registry returned HTTP %d", e.StatusCode)
+}
+
+// isRetryablePushError reports whether err looks like a transient registry
+// failure (5xx, 429 honoring Retry-After, a dropped connection, or a
+// temporary network error) worth retrying, and how long to wait before the
+// next attempt if the server told us.
+func isRetryablePushError(err error) (bool, time.Duration) {
+	if err == nil {
+		return false, 0
+	}
+
+	var statusErr *HTTPStatusError
+	if goerrors.As(err, &statusErr) {
+		if statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests {
+			return true, statusErr.RetryAfter
+		}
+		return false, 0
+	}
+
+	cause := errors.Cause(err)
+	if cause == io.EOF || cause == io.ErrUnexpectedEOF {
+		return true, 0
+	}
+
+	var netErr net.Error
+	if goerrors.As(cause, &netErr) {
+		return netErr.Temporary(), 0
+	}
+
+	return false, 0
+}
+
+// prefixWriter tags every line written to w with a target name, so that
+// interleaved progress from concurrent pushes stays attributable. mu is
+// shared across every prefixWriter writing to the same underlying output so
+// concurrent writers can't interleave partial lines.
+type prefixWriter struct {
+	prefix string
+	w      io.Writer
+	mu     *sync.Mutex
+}
+
+// newPrefixWriter creates a prefixWriter. Pass a shared mu when multiple
+// prefixWriters write to the same underlying w concurrently; a nil mu gets
+// one of its own for single-writer use.
+func newPrefixWriter(prefix string, w io.Writer, mu *sync.Mutex) *prefixWriter {
+	if mu == nil {
+		mu = &sync.Mutex{}
+	}
+	return &prefixWriter{prefix: prefix, w: w, mu: mu}
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, line := range strings.SplitAfter(string(b), "\n") {
+		if line == "" {
+			continue
+		}
+		if _, err := fmt.Fprintf(p.w, "[%s] %s", p.prefix, line); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
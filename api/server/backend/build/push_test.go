@@ -0,0 +1,163 @@
+package build
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/progress"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+type pushOverride struct {
+	fakeImageComponent
+	push func(ctx context.Context, image, tag string, metaHeaders map[string][]string, authConfig *types.AuthConfig, output progress.Output) error
+}
+
+func (p pushOverride) PushImage(ctx context.Context, image, tag string, metaHeaders map[string][]string, authConfig *types.AuthConfig, output progress.Output) error {
+	return p.push(ctx, image, tag, metaHeaders, authConfig, output)
+}
+
+func TestIsRetryablePushErrorHTTPStatus(t *testing.T) {
+	cases := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{"server error", &HTTPStatusError{StatusCode: http.StatusBadGateway}, true},
+		{"too many requests", &HTTPStatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: 2 * time.Second}, true},
+		{"not found", &HTTPStatusError{StatusCode: http.StatusNotFound}, false},
+		{"wrapped server error", errors.Wrap(&HTTPStatusError{StatusCode: http.StatusServiceUnavailable}, "push failed"), true},
+		{"EOF", io.EOF, true},
+		{"wrapped EOF", errors.Wrap(io.EOF, "push failed"), true},
+		{"unrelated error", errors.New("tag contains the digits 503 and 429"), false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			retryable, _ := isRetryablePushError(c.err)
+			if retryable != c.retryable {
+				t.Fatalf("isRetryablePushError(%v) = %v, want %v", c.err, retryable, c.retryable)
+			}
+		})
+	}
+}
+
+func TestIsRetryablePushErrorHonorsRetryAfter(t *testing.T) {
+	_, retryAfter := isRetryablePushError(&HTTPStatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: 5 * time.Second})
+	if retryAfter != 5*time.Second {
+		t.Fatalf("got retryAfter %v, want 5s", retryAfter)
+	}
+}
+
+func TestPushTargetWithRetrySucceedsAfterTransientFailures(t *testing.T) {
+	var attempts int32
+	component := pushOverride{push: func(ctx context.Context, image, tag string, metaHeaders map[string][]string, authConfig *types.AuthConfig, output progress.Output) error {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			return &HTTPStatusError{StatusCode: http.StatusBadGateway}
+		}
+		return nil
+	}}
+
+	b := &Backend{imageComponent: component, registryService: fakeRegistryService{}}
+	var out bytes.Buffer
+	err := b.pushTargetWithRetry(context.Background(), "img", types.BuildPushTarget{Ref: "example.com/foo:latest"}, nil, &out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("got %d attempts, want 3", attempts)
+	}
+}
+
+func TestPushTargetWithRetryGivesUpOnPermanentError(t *testing.T) {
+	var attempts int32
+	component := pushOverride{push: func(ctx context.Context, image, tag string, metaHeaders map[string][]string, authConfig *types.AuthConfig, output progress.Output) error {
+		atomic.AddInt32(&attempts, 1)
+		return &HTTPStatusError{StatusCode: http.StatusUnauthorized}
+	}}
+
+	b := &Backend{imageComponent: component, registryService: fakeRegistryService{}}
+	var out bytes.Buffer
+	err := b.pushTargetWithRetry(context.Background(), "img", types.BuildPushTarget{Ref: "example.com/foo:latest"}, nil, &out)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if attempts != 1 {
+		t.Fatalf("got %d attempts, want 1 (no retry on permanent error)", attempts)
+	}
+}
+
+func TestPushAllRunsTargetsConcurrentlyAndReportsFailure(t *testing.T) {
+	var inFlight, maxInFlight int32
+	component := pushOverride{push: func(ctx context.Context, image, tag string, metaHeaders map[string][]string, authConfig *types.AuthConfig, output progress.Output) error {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		if strings.Contains(image, "bad") {
+			return errors.New("permanent failure")
+		}
+		return nil
+	}}
+
+	b := &Backend{imageComponent: component, registryService: fakeRegistryService{}}
+	targets := []types.BuildPushTarget{
+		{Ref: "example.com/good1:latest"},
+		{Ref: "example.com/good2:latest"},
+		{Ref: "example.com/bad:latest"},
+	}
+	var out bytes.Buffer
+	err := b.pushAll(context.Background(), "img", targets, nil, &out)
+	if err == nil {
+		t.Fatal("expected an error from the failing target")
+	}
+	if maxInFlight < 2 {
+		t.Fatalf("expected pushes to run concurrently, max in flight was %d", maxInFlight)
+	}
+}
+
+func TestPrefixWriterTagsEachLine(t *testing.T) {
+	var out bytes.Buffer
+	w := newPrefixWriter("example.com/foo", &out, nil)
+	if _, err := w.Write([]byte("line one\nline two\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "[example.com/foo] line one\n[example.com/foo] line two\n"
+	if out.String() != want {
+		t.Fatalf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestPrefixWriterSharedMutexSerializesConcurrentWriters(t *testing.T) {
+	var out bytes.Buffer
+	mu := &sync.Mutex{}
+	a := newPrefixWriter("a", &out, mu)
+	b := newPrefixWriter("b", &out, mu)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() { defer wg.Done(); a.Write([]byte("from a\n")) }()
+		go func() { defer wg.Done(); b.Write([]byte("from b\n")) }()
+	}
+	wg.Wait()
+
+	for _, line := range strings.Split(strings.TrimRight(out.String(), "\n"), "\n") {
+		if line != "[a] from a" && line != "[b] from b" {
+			t.Fatalf("corrupted/interleaved line: %q", line)
+		}
+	}
+}
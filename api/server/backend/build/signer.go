@@ -0,0 +1,47 @@
+package build
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/image"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// Signer produces a signature for a built image before it is pushed.
+// Returning an error aborts the build, so an image that fails to sign is
+// never published.
+type Signer interface {
+	Sign(ctx context.Context, ref reference.Named, imageID image.ID) ([]byte, error)
+}
+
+// SignaturePusher publishes a signature as an OCI artifact alongside the
+// image it covers, authenticating with authConfig so the publish uses the
+// same credentials the build itself pushes with.
+type SignaturePusher interface {
+	PushSignature(ctx context.Context, ref reference.Named, imageID image.ID, authConfig *types.AuthConfig, sig []byte) error
+}
+
+// NoopSigner never signs anything. It is the default Signer, so building
+// without a signing policy behaves exactly as it did before signing existed.
+type NoopSigner struct{}
+
+// Sign implements Signer.
+func (NoopSigner) Sign(ctx context.Context, ref reference.Named, imageID image.ID) ([]byte, error) {
+	return nil, nil
+}
+
+// signTags runs signer against every tag about to be pushed, aborting on the
+// first failure.
+func signTags(ctx context.Context, signer Signer, tags []reference.Named, imageID image.ID, output io.Writer) error {
+	for _, tag := range tags {
+		fmt.Fprintf(output, "Signing %s\n", tag.String())
+		if _, err := signer.Sign(ctx, tag, imageID); err != nil {
+			return errors.Wrapf(err, "failed to sign %s", tag.String())
+		}
+	}
+	return nil
+}
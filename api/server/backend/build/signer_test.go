@@ -0,0 +1,118 @@
+package build
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/image"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+type fakeSigner struct {
+	sign func(ctx context.Context, ref reference.Named, imageID image.ID) ([]byte, error)
+}
+
+func (f fakeSigner) Sign(ctx context.Context, ref reference.Named, imageID image.ID) ([]byte, error) {
+	return f.sign(ctx, ref, imageID)
+}
+
+func mustParseRef(t *testing.T, s string) reference.Named {
+	t.Helper()
+	ref, err := reference.ParseNormalizedNamed(s)
+	if err != nil {
+		t.Fatalf("failed to parse ref %q: %v", s, err)
+	}
+	return ref
+}
+
+func TestNoopSignerSignsNothing(t *testing.T) {
+	sig, err := (NoopSigner{}).Sign(context.Background(), mustParseRef(t, "example.com/foo:latest"), image.ID("abc"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sig != nil {
+		t.Fatalf("expected no signature, got %v", sig)
+	}
+}
+
+func TestSignTagsAbortsOnFirstFailure(t *testing.T) {
+	var signed []string
+	signer := fakeSigner{sign: func(ctx context.Context, ref reference.Named, imageID image.ID) ([]byte, error) {
+		signed = append(signed, ref.String())
+		if len(signed) == 2 {
+			return nil, errors.New("signing rejected")
+		}
+		return []byte("sig"), nil
+	}}
+
+	tags := []reference.Named{
+		mustParseRef(t, "example.com/one:latest"),
+		mustParseRef(t, "example.com/two:latest"),
+		mustParseRef(t, "example.com/three:latest"),
+	}
+
+	var out bytes.Buffer
+	err := signTags(context.Background(), signer, tags, image.ID("abc"), &out)
+	if err == nil {
+		t.Fatal("expected error to propagate from a rejected signature")
+	}
+	if len(signed) != 2 {
+		t.Fatalf("expected signing to stop after the rejection, signed %v", signed)
+	}
+}
+
+type signaturePusherFunc func(ctx context.Context, ref reference.Named, imageID image.ID, authConfig *types.AuthConfig, sig []byte) error
+
+func (f signaturePusherFunc) PushSignature(ctx context.Context, ref reference.Named, imageID image.ID, authConfig *types.AuthConfig, sig []byte) error {
+	return f(ctx, ref, imageID, authConfig, sig)
+}
+
+func TestCosignSignerProducesVerifiableSignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	var pushedSig []byte
+	var pushedAuth *types.AuthConfig
+	pusher := signaturePusherFunc(func(ctx context.Context, ref reference.Named, imageID image.ID, authConfig *types.AuthConfig, sig []byte) error {
+		pushedSig = sig
+		pushedAuth = authConfig
+		return nil
+	})
+
+	authConfigs := map[string]types.AuthConfig{"example.com": {Username: "user"}}
+	signer := NewCosignSigner(pusher, fakeRegistryService{}, authConfigs, priv)
+
+	imageID := image.ID("sha256:deadbeef")
+	sig, err := signer.Sign(context.Background(), mustParseRef(t, "example.com/foo:latest"), imageID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !ed25519.Verify(pub, []byte(imageID.String()), sig) {
+		t.Fatal("signature does not verify against the signer's public key")
+	}
+	if !bytes.Equal(sig, pushedSig) {
+		t.Fatal("the signature returned to the caller doesn't match what was pushed")
+	}
+	if pushedAuth == nil || pushedAuth.Username != "user" {
+		t.Fatalf("expected the signature push to use the resolved registry credentials, got %+v", pushedAuth)
+	}
+}
+
+func TestCosignSignerPropagatesPushRejection(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(nil)
+	pusher := signaturePusherFunc(func(ctx context.Context, ref reference.Named, imageID image.ID, authConfig *types.AuthConfig, sig []byte) error {
+		return errors.New("registry rejected signature artifact")
+	})
+
+	signer := NewCosignSigner(pusher, fakeRegistryService{}, nil, priv)
+	if _, err := signer.Sign(context.Background(), mustParseRef(t, "example.com/foo:latest"), image.ID("sha256:deadbeef")); err == nil {
+		t.Fatal("expected the push rejection to propagate")
+	}
+}
@@ -0,0 +1,82 @@
+package build
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/backend"
+	"golang.org/x/net/context"
+)
+
+// Backend is the subset of the build backend this router depends on.
+type Backend interface {
+	Build(ctx context.Context, config backend.BuildConfig) (string, error)
+}
+
+// Router serves the image build endpoint.
+type Router struct {
+	backend Backend
+}
+
+// NewRouter creates a Router that dispatches build requests to backend.
+func NewRouter(backend Backend) *Router {
+	return &Router{backend: backend}
+}
+
+// Handler returns the POST /build HTTP handler.
+func (rt *Router) Handler() http.HandlerFunc {
+	return rt.postBuild
+}
+
+// postBuild parses the build endpoint's query options, including
+// ?output=type=oci,ref=..., into a types.ImageBuildOptions and invokes
+// Backend.Build, streaming the build's stdout to the response.
+func (rt *Router) postBuild(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	// r.URL.Query drops any pair whose raw text contains ';', the separator
+	// the output option uses between refs, so output is read from the raw
+	// query instead.
+	var rawOutput string
+	for _, pair := range strings.Split(r.URL.RawQuery, "&") {
+		if value := strings.TrimPrefix(pair, "output="); value != pair {
+			unescaped, err := url.QueryUnescape(value)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			rawOutput = unescaped
+			break
+		}
+	}
+
+	output, err := parseOutputOption(rawOutput)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	options := types.ImageBuildOptions{
+		Tags:      query["t"],
+		CacheFrom: query["cachefrom"],
+		PushAs:    query.Get("pushas"),
+		Squash:    query.Get("squash") == "1",
+		Output:    output,
+	}
+
+	config := backend.BuildConfig{
+		Options:        options,
+		ProgressWriter: backend.ProgressWriter{StdoutFormatter: w},
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	imageID, err := rt.backend.Build(r.Context(), config)
+	if err != nil {
+		fmt.Fprintf(w, "Error: %v\n", err)
+		return
+	}
+	fmt.Fprintf(w, "Successfully built %s\n", imageID)
+}
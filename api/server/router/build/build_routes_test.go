@@ -0,0 +1,64 @@
+package build
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/docker/api/types/backend"
+	"golang.org/x/net/context"
+)
+
+type fakeBuildBackend struct {
+	config backend.BuildConfig
+	called bool
+}
+
+func (f *fakeBuildBackend) Build(ctx context.Context, config backend.BuildConfig) (string, error) {
+	f.called = true
+	f.config = config
+	return "abc123", nil
+}
+
+func TestPostBuildParsesOutputOptionAndInvokesBackend(t *testing.T) {
+	backendFake := &fakeBuildBackend{}
+	rt := NewRouter(backendFake)
+
+	req := httptest.NewRequest(http.MethodPost, "/build?output=type=oci,ref=foo/bar:latest&t=foo/bar:latest", nil)
+	w := httptest.NewRecorder()
+
+	rt.Handler()(w, req)
+
+	if !backendFake.called {
+		t.Fatal("expected the build backend to be invoked")
+	}
+	if backendFake.config.Options.Output == nil {
+		t.Fatal("expected the output option to be parsed onto the build config")
+	}
+	if len(backendFake.config.Options.Output.Refs) != 1 || backendFake.config.Options.Output.Refs[0] != "foo/bar:latest" {
+		t.Fatalf("got refs %v, want [foo/bar:latest]", backendFake.config.Options.Output.Refs)
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+	if w.Body.String() != "Successfully built abc123\n" {
+		t.Fatalf("got body %q", w.Body.String())
+	}
+}
+
+func TestPostBuildRejectsInvalidOutputOption(t *testing.T) {
+	backendFake := &fakeBuildBackend{}
+	rt := NewRouter(backendFake)
+
+	req := httptest.NewRequest(http.MethodPost, "/build?output=type=tar", nil)
+	w := httptest.NewRecorder()
+
+	rt.Handler()(w, req)
+
+	if backendFake.called {
+		t.Fatal("expected the build backend not to be invoked for an invalid output option")
+	}
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
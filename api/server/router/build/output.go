@@ -0,0 +1,45 @@
+package build
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+)
+
+// parseOutputOption parses the build endpoint's ?output=type=oci,ref=...
+// query value into the ImageExportOutput the build backend understands.
+// The postBuild handler calls this before invoking Backend.Build so a
+// malformed or unsupported output option is rejected with a 400 instead of
+// being silently ignored. Only type=oci is currently supported.
+//
+// This is parsed by hand rather than with net/url.ParseQuery: Go's query
+// parser rejects the ';' this option uses to separate multiple refs.
+func parseOutputOption(raw string) (*types.ImageExportOutput, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var typ string
+	var refs []string
+	for _, part := range strings.Split(raw, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid output option %q: expected key=value", part)
+		}
+		switch key {
+		case "type":
+			typ = value
+		case "ref":
+			refs = strings.Split(value, ";")
+		default:
+			return nil, fmt.Errorf("unsupported output option %q", key)
+		}
+	}
+
+	if typ != "oci" {
+		return nil, fmt.Errorf("unsupported output type %q: only \"oci\" is supported", typ)
+	}
+
+	return &types.ImageExportOutput{Refs: refs}, nil
+}
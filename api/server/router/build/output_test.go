@@ -0,0 +1,48 @@
+package build
+
+import "testing"
+
+func TestParseOutputOptionEmpty(t *testing.T) {
+	output, err := parseOutputOption("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output != nil {
+		t.Fatalf("expected nil output, got %+v", output)
+	}
+}
+
+func TestParseOutputOptionOCI(t *testing.T) {
+	output, err := parseOutputOption("type=oci")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output == nil {
+		t.Fatal("expected non-nil output")
+	}
+	if len(output.Refs) != 0 {
+		t.Fatalf("expected no refs, got %v", output.Refs)
+	}
+}
+
+func TestParseOutputOptionOCIWithRefs(t *testing.T) {
+	output, err := parseOutputOption("type=oci,ref=foo/bar:latest;foo/baz:latest")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"foo/bar:latest", "foo/baz:latest"}
+	if len(output.Refs) != len(want) {
+		t.Fatalf("got refs %v, want %v", output.Refs, want)
+	}
+	for i, ref := range want {
+		if output.Refs[i] != ref {
+			t.Fatalf("got refs %v, want %v", output.Refs, want)
+		}
+	}
+}
+
+func TestParseOutputOptionUnsupportedType(t *testing.T) {
+	if _, err := parseOutputOption("type=tar"); err == nil {
+		t.Fatal("expected error for unsupported output type")
+	}
+}
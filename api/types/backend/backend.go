@@ -0,0 +1,18 @@
+package backend
+
+import (
+	"io"
+
+	"github.com/docker/docker/api/types"
+)
+
+// ProgressWriter carries the writers a build streams its output through.
+type ProgressWriter struct {
+	StdoutFormatter io.Writer
+}
+
+// BuildConfig holds everything needed to run a single build.
+type BuildConfig struct {
+	Options        types.ImageBuildOptions
+	ProgressWriter ProgressWriter
+}
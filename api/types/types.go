@@ -0,0 +1,73 @@
+package types
+
+// AuthConfig holds the credentials used to authenticate against a registry.
+type AuthConfig struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	ServerAddress string `json:"serveraddress,omitempty"`
+}
+
+// PostCommit describes a user-supplied command to run inside an ephemeral
+// container based on the freshly built image, before it is tagged or
+// pushed. Exactly one of Script or Command should be set; Script is run
+// through a shell, Command is used as an explicit argv.
+type PostCommit struct {
+	Script  string
+	Command []string
+}
+
+// Empty reports whether the hook has nothing to run.
+func (p *PostCommit) Empty() bool {
+	return p == nil || (p.Script == "" && len(p.Command) == 0)
+}
+
+// Cmd returns the argv to run for this hook.
+func (p *PostCommit) Cmd() []string {
+	if len(p.Command) > 0 {
+		return p.Command
+	}
+	return []string{"/bin/sh", "-c", p.Script}
+}
+
+// ImageExportOutput configures streaming a build's result out as an OCI
+// image layout in addition to committing it to the local image store; the
+// image is committed and tagged whether or not it is exported. Refs, if set, are recorded so consumers can resolve a name
+// without a registry.
+type ImageExportOutput struct {
+	Refs []string
+}
+
+// BuildPushTarget is a single destination a build result should be pushed
+// to after a successful build.
+type BuildPushTarget struct {
+	Ref      string
+	Platform string
+	AuthKey  string
+}
+
+// ImageBuildOptions holds the configuration for a single build request.
+type ImageBuildOptions struct {
+	Tags        []string
+	Squash      bool
+	AuthConfigs map[string]AuthConfig
+
+	// CacheFrom lists images to resolve, pulling them if necessary, so the
+	// builder can use them as layer cache sources.
+	CacheFrom []string
+
+	// PushAs is the deprecated single-target push option, kept as a compat
+	// shim for callers that haven't moved to PushTargets.
+	PushAs string
+
+	// PushTargets supersedes PushAs: every entry is pushed after a
+	// successful build.
+	PushTargets []BuildPushTarget
+
+	// PostCommit, if set, is run inside an ephemeral container based on the
+	// freshly built image before the image is tagged or pushed.
+	PostCommit *PostCommit
+
+	// Output, if set, also streams the build result out as an OCI image
+	// layout; the result is committed to the image store either way.
+	Output *ImageExportOutput
+}
@@ -0,0 +1,35 @@
+package registry
+
+import (
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+)
+
+// IndexInfo identifies the registry index backing a repository.
+type IndexInfo struct {
+	Name     string
+	Secure   bool
+	Official bool
+}
+
+// RepositoryInfo describes a repository resolved from a reference.
+type RepositoryInfo struct {
+	Index *IndexInfo
+}
+
+// Service resolves references against configured registries and mirrors.
+type Service interface {
+	ResolveRepository(name reference.Named) (*RepositoryInfo, error)
+}
+
+// ResolveAuthConfig looks up the credentials configured for the registry
+// backing index, falling back to the zero value if none were supplied.
+func ResolveAuthConfig(authConfigs map[string]types.AuthConfig, index *IndexInfo) types.AuthConfig {
+	if index == nil {
+		return types.AuthConfig{}
+	}
+	if authConfig, ok := authConfigs[index.Name]; ok {
+		return authConfig
+	}
+	return types.AuthConfig{}
+}